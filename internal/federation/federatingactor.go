@@ -18,6 +18,7 @@
 package federation
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -25,6 +26,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"codeberg.org/gruf/go-kv"
@@ -37,69 +40,256 @@ import (
 	"github.com/superseriousbusiness/gotosocial/internal/log"
 )
 
-// IsASMediaType will return whether the given content-type string
-// matches one of the 2 possible ActivityStreams incoming content types:
-// - application/activity+json
-// - application/ld+json;profile=https://w3.org/ns/activitystreams
-//
-// Where for the above we are leniant with whitespace and quotes.
-func IsASMediaType(ct string) bool {
-	var (
-		// First content-type part,
-		// contains the application/...
-		p1 string = ct //nolint:revive
+// asNamespaceIRI is the ActivityStreams 2.0 JSON-LD context IRI,
+// in the two forms real-world implementations send it in.
+const (
+	asNamespaceIRI     = "https://www.w3.org/ns/activitystreams"
+	asNamespaceIRIHTTP = "http://www.w3.org/ns/activitystreams"
+)
+
+// maxContextPeekBytes bounds how much of an ld+json body we'll
+// look at to confirm an AS2 `@context`, when the content-type
+// itself didn't carry a `profile` parameter telling us outright.
+const maxContextPeekBytes = 4096
+
+// mediaTypeCandidate is one entry parsed out of a Content-Type or
+// Accept header's comma-separated list, per RFC 7231 §5.3.2.
+type mediaTypeCandidate struct {
+	typ    string
+	params map[string]string
+	q      float64
+}
+
+// parseMediaTypeList parses a (possibly multi-valued, possibly
+// q-weighted) media type header into its component candidates,
+// tolerating the whitespace/quoting variance real-world AP
+// implementations send.
+func parseMediaTypeList(header string) []mediaTypeCandidate {
+	var candidates []mediaTypeCandidate
+
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
 
-		// Second content-type part,
-		// contains AS IRI if provided
-		p2 string
-	)
+		parts := strings.Split(entry, ";")
+		typ := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		c := mediaTypeCandidate{
+			typ:    typ,
+			params: make(map[string]string),
+			q:      1, // default weight per RFC 7231
+		}
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			key := strings.ToLower(strings.TrimSpace(kv[0]))
+			val := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+
+			if key == "q" {
+				if q, err := strconv.ParseFloat(val, 64); err == nil {
+					c.q = q
+				}
+				continue
+			}
+
+			c.params[key] = val
+		}
 
-	// Split content-type by semi-colon.
-	sep := strings.IndexByte(ct, ';')
-	if sep >= 0 {
-		p1 = ct[:sep]
-		p2 = ct[sep+1:]
+		candidates = append(candidates, c)
 	}
 
-	// Trim any ending space from the
-	// main content-type part of string.
-	p1 = strings.TrimRight(p1, " ")
+	// Stable sort, highest quality first; entries with
+	// equal q keep their original relative order.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
 
-	switch p1 {
+	return candidates
+}
+
+// isASCandidate reports whether a single parsed media type
+// candidate counts as an acceptable incoming ActivityStreams
+// representation, peeking at body (which may be nil, eg. when
+// negotiating an Accept header rather than checking a body) to
+// confirm an AS2 `@context` when `profile` isn't given explicitly.
+func isASCandidate(c mediaTypeCandidate, body []byte) bool {
+	if c.q <= 0 {
+		return false
+	}
+
+	switch c.typ {
 	case "application/activity+json":
-		return p2 == ""
+		return true
 
 	case "application/ld+json":
-		// Trim all start/end space.
-		p2 = strings.Trim(p2, " ")
-
-		// Drop any quotes around the URI str.
-		p2 = strings.ReplaceAll(p2, "\"", "")
+		if profile, ok := c.params["profile"]; ok {
+			return profile == asNamespaceIRI || profile == asNamespaceIRIHTTP
+		}
 
-		// End part must be a ref to the main AS namespace IRI.
-		return p2 == "profile=https://www.w3.org/ns/activitystreams"
+		// No profile parameter (eg. Pixelfed/Friendica/Hubzilla
+		// sometimes just send `application/ld+json;charset=utf-8`).
+		// Fall back to sniffing the body for an AS2 `@context`
+		// before accepting it.
+		return body != nil && bodyReferencesASContext(body)
 
 	default:
 		return false
 	}
 }
 
+// bodyReferencesASContext does a bounded, best-effort check that
+// the first maxContextPeekBytes of body contain an `@context`
+// mentioning the ActivityStreams namespace. This is deliberately
+// a substring scan rather than a full JSON decode: the body may
+// be truncated at the peek boundary, and a malformed/truncated
+// decode would otherwise report a false negative.
+func bodyReferencesASContext(body []byte) bool {
+	peek := body
+	if len(peek) > maxContextPeekBytes {
+		peek = peek[:maxContextPeekBytes]
+	}
+
+	if !bytes.Contains(peek, []byte(`"@context"`)) {
+		return false
+	}
+
+	return bytes.Contains(peek, []byte(asNamespaceIRI)) ||
+		bytes.Contains(peek, []byte(asNamespaceIRIHTTP))
+}
+
+// IsASMediaType will return whether the given content-type string
+// matches one of the acceptable ActivityStreams incoming content
+// types:
+//   - application/activity+json
+//   - application/ld+json, with a profile=.../ns/activitystreams
+//     parameter
+//
+// Full RFC 7231 media-type lists are accepted (multiple values,
+// q= weights, extra parameters like charset in any order); a
+// candidate with q=0 is treated as explicitly excluded.
+//
+// This is a widely-used helper with callers outside this package
+// that only ever had a content-type string to hand, so its
+// signature can't change to require a body; use
+// IsASMediaTypeWithBody instead where the body is available and
+// an ld+json without a profile parameter should be sniffed rather
+// than rejected outright.
+func IsASMediaType(ct string) bool {
+	return IsASMediaTypeWithBody(ct, nil)
+}
+
+// IsASMediaTypeWithBody is IsASMediaType, but additionally sniffs
+// body (which may be nil) for an AS2 `@context` when the
+// content-type is application/ld+json without an explicit profile
+// parameter, rather than rejecting it outright. Used internally
+// by PostInboxScheme/PostOutboxScheme, which always have the body
+// to hand.
+func IsASMediaTypeWithBody(ct string, body []byte) bool {
+	for _, c := range parseMediaTypeList(ct) {
+		if isASCandidate(c, body) {
+			return true
+		}
+	}
+	return false
+}
+
+// NegotiateASMediaType parses an Accept header (as sent by a
+// remote peer dereferencing one of our objects, or built by us
+// when dereferencing a remote one) and returns the most-preferred
+// ActivityStreams content-type string to use, honouring the
+// sender's q= weights and wildcards. It returns "" if nothing in
+// accept is compatible with ActivityStreams at all.
+func NegotiateASMediaType(accept string) string {
+	const preferred = "application/activity+json"
+
+	for _, c := range parseMediaTypeList(accept) {
+		if c.q <= 0 {
+			continue
+		}
+
+		switch c.typ {
+		case "application/activity+json":
+			return preferred
+
+		case "application/ld+json":
+			if isASCandidate(c, nil) {
+				return preferred
+			}
+			// No profile to confirm AS2 specifically, but the
+			// client did ask for ld+json; offer it with our
+			// profile so they can recognise it either way.
+			return "application/ld+json; profile=" + asNamespaceIRI
+
+		case "application/*", "*/*":
+			return preferred
+		}
+	}
+
+	return ""
+}
+
 // federatingActor wraps the pub.FederatingActor
 // with some custom GoToSocial-specific logic.
 type federatingActor struct {
 	sideEffectActor pub.DelegateActor
+	c2s             pub.SocialProtocol
+	keys            PublicKeyGetter
+	signingKeys     AssertionKeyGetter
+	policies        PolicyChain
+	tombstones      TombstoneStore
+	signer          OutgoingSigner
 	wrapped         pub.FederatingActor
 }
 
-// newFederatingActor returns a federatingActor.
-func newFederatingActor(c pub.CommonBehavior, s2s pub.FederatingProtocol, db pub.Database, clock pub.Clock) pub.FederatingActor {
-	sideEffectActor := pub.NewSideEffectActor(c, s2s, nil, db, clock)
-	sideEffectActor.Serialize = ap.Serialize // hook in our own custom Serialize function
-
-	return &federatingActor{
+// newFederatingActor returns a federatingActor. It enables both
+// halves of the actor: S2S (server-to-server, ie., federation
+// with other instances) and C2S (client-to-server, ie., allowing
+// ActivityPub-native clients to post directly to a local
+// account's outbox instead of going via the Mastodon-compatible
+// REST API).
+//
+// keys is used to dereference the public key of a signer on an
+// incoming request; see verifyHTTPSignature in httpsignature.go.
+// signingKeys is used to attach an outgoing FEP-8b32 object
+// integrity proof to activities we send; see ldproof.go.
+// policies is the admission/policy pipeline run over every
+// resolved incoming Activity, before AuthorizePostInbox; see
+// policy.go. A nil or empty chain accepts everything, preserving
+// prior behaviour.
+// signer is used by Send to sign outgoing deliveries with hs2019,
+// rsa-sha256, ed25519 or ecdsa-p256-sha256 (RFC 9421 or legacy
+// draft-cavage-12, per how it was constructed); see
+// NewOutgoingSigner in httpsignature.go. A nil signer leaves
+// outgoing requests to whatever signing the transport controller
+// applies on its own.
+func newFederatingActor(c pub.CommonBehavior, s2s pub.FederatingProtocol, c2s pub.SocialProtocol, db pub.Database, clock pub.Clock, keys PublicKeyGetter, signingKeys AssertionKeyGetter, policies PolicyChain, signer OutgoingSigner) pub.FederatingActor {
+	sideEffectActor := pub.NewSideEffectActor(c, s2s, c2s, db, clock)
+
+	f := &federatingActor{
 		sideEffectActor: sideEffectActor,
-		wrapped:         pub.NewCustomActor(sideEffectActor, false, true, clock),
+		c2s:             c2s,
+		keys:            keys,
+		signingKeys:     signingKeys,
+		policies:        policies,
+		tombstones:      newMemoryTombstoneStore(),
+		signer:          signer,
 	}
+
+	// Hook in our own custom Serialize function, which wraps the
+	// default ap.Serialize to also attach a FEP-8b32 object
+	// integrity proof, signed with the sending account's Ed25519
+	// assertionMethod key, to every outgoing Activity.
+	sideEffectActor.Serialize = f.serializeWithProof
+
+	f.wrapped = pub.NewCustomActor(sideEffectActor, true, true, clock)
+	return f
 }
 
 // PostInboxScheme is a reimplementation of the default baseActor
@@ -119,22 +309,61 @@ func (f *federatingActor) PostInboxScheme(ctx context.Context, w http.ResponseWr
 			{"path", r.URL.Path},
 		}...)
 
+	// Read the body once; we need the raw bytes both to check the
+	// Content-Type (an ld+json without an explicit profile param
+	// needs its body peeked at to confirm the AS2 @context), and
+	// to verify the HTTP signature's body digest and resolve the
+	// Activity below. The request body can only be read once.
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		err = fmt.Errorf("error reading request body: %w", err)
+		return false, gtserror.NewErrorInternalError(err)
+	}
+
 	// Ensure valid ActivityPub Content-Type.
 	// https://www.w3.org/TR/activitypub/#server-to-server-interactions
-	if ct := r.Header.Get("Content-Type"); !IsASMediaType(ct) {
+	if ct := r.Header.Get("Content-Type"); !IsASMediaTypeWithBody(ct, body) {
 		const ct1 = "application/activity+json"
 		const ct2 = "application/ld+json;profile=https://w3.org/ns/activitystreams"
 		err := fmt.Errorf("Content-Type %s not acceptable, this endpoint accepts: [%q %q]", ct, ct1, ct2)
 		return false, gtserror.NewErrorNotAcceptable(err)
 	}
 
-	// Authenticate request by checking http signature.
-	ctx, authenticated, err := f.sideEffectActor.AuthenticatePostInbox(ctx, w, r)
+	// r.Body has already been drained above; restore it before
+	// calling into go-fed so anything downstream that still reads
+	// r.Body (including AuthenticatePostInbox below) sees the same
+	// bytes we already have in hand.
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Run go-fed's own baseline AuthenticatePostInbox first, but
+	// only to pick up the ctx it seeds for the block/context state
+	// that AuthorizePostInbox further down depends on; skipping it
+	// entirely would silently disable that inbound moderation check.
+	// Its own boolean result is *not* treated as a gate: go-fed's
+	// baseline verification only understands the legacy
+	// draft-cavage-12 scheme with rsa-sha256, so a peer signing with
+	// ed25519, ecdsa-p256-sha256, or RFC 9421's Signature-Input/
+	// Signature pair would fail this check and never reach our own
+	// verifyHTTPSignature below, defeating the point of adding
+	// support for those algorithms at all. verifyHTTPSignature alone
+	// decides whether the request is authenticated.
+	ctx, _, err = f.sideEffectActor.AuthenticatePostInbox(ctx, w, r)
 	if err != nil {
 		return false, gtserror.NewErrorInternalError(err)
 	}
 
-	if !authenticated {
+	// Authenticate request by verifying its HTTP signature; this
+	// understands both the legacy draft-cavage-12 `Signature:`
+	// header and RFC 9421's `Signature-Input:`/`Signature:` pair,
+	// negotiating hs2019, rsa-sha256, ed25519 and
+	// ecdsa-p256-sha256 as appropriate, and enforces the body digest
+	// check that go-fed's baseline verification doesn't. See
+	// httpsignature.go.
+	requester, err := verifyHTTPSignature(ctx, r, body, f.keys)
+	if err != nil {
+		l.Debugf("could not verify http signature: %s", err)
+		writeSignatureChallenge(w)
 		err = errors.New("not authenticated")
 		return false, gtserror.NewErrorUnauthorized(err)
 	}
@@ -145,11 +374,19 @@ func (f *federatingActor) PostInboxScheme(ctx context.Context, w http.ResponseWr
 	*/
 
 	// Obtain the activity; reject unknown activities.
-	activity, errWithCode := resolveActivity(ctx, r)
+	activity, rawActivity, errWithCode := f.resolveActivity(ctx, body)
 	if errWithCode != nil {
 		return false, errWithCode
 	}
 
+	activityID := activity.GetJSONLDId().String()
+	if f.tombstones.IsTombstoned(activityID) {
+		// Already dropped via PolicyTombstone once; don't even
+		// bother re-running the policy chain against a re-delivery
+		// of the same Activity.
+		return true, nil
+	}
+
 	// Set additional context data. Primarily this means
 	// looking at the Activity and seeing which IRIs are
 	// involved in it tangentially.
@@ -158,6 +395,36 @@ func (f *federatingActor) PostInboxScheme(ctx context.Context, w http.ResponseWr
 		return false, gtserror.NewErrorInternalError(err)
 	}
 
+	// Run the activity through the configurable admission/policy
+	// pipeline before handing it to AuthorizePostInbox. Unlike
+	// blocks (which are a fixed, binary relationship), policies
+	// can allowlist/denylist by domain, rate-limit, cap size, or
+	// defer to an operator-supplied script; see policy.go.
+	if len(f.policies) > 0 {
+		verdict, err := f.policies.Evaluate(ctx, requester, activity, rawActivity)
+		if err != nil {
+			var withCode gtserror.WithCode
+			if errors.As(err, &withCode) {
+				return false, withCode
+			}
+			return false, gtserror.NewErrorForbidden(err)
+		}
+
+		switch verdict {
+		case PolicyDrop:
+			// Tell the sender we accepted it, but do nothing more.
+			return true, nil
+		case PolicyTombstone:
+			// As PolicyDrop, but also remember this Activity's ID
+			// so a re-delivery is dropped immediately next time.
+			f.tombstones.Tombstone(activityID)
+			return true, nil
+		case PolicyReject:
+			err = errors.New("rejected by policy")
+			return false, gtserror.NewErrorForbidden(err)
+		}
+	}
+
 	// Check authorization of the activity; this will include blocks.
 	authorized, err := f.sideEffectActor.AuthorizePostInbox(ctx, w, activity)
 	if err != nil {
@@ -243,21 +510,26 @@ func (f *federatingActor) PostInboxScheme(ctx context.Context, w http.ResponseWr
 }
 
 // resolveActivity is a util function for pulling a
-// pub.Activity type out of an incoming POST request.
-func resolveActivity(ctx context.Context, r *http.Request) (pub.Activity, gtserror.WithCode) {
-	// Tidy up when done.
-	defer r.Body.Close()
-
-	b, err := io.ReadAll(r.Body)
-	if err != nil {
-		err = fmt.Errorf("error reading request body: %w", err)
-		return nil, gtserror.NewErrorInternalError(err)
-	}
-
+// pub.Activity type out of an already-read request body.
+//
+// If the parsed document carries an embedded FEP-8b32 `proof`
+// property, it's verified against the issuing actor's
+// assertionMethod key here too; an invalid proof is rejected as
+// a bad request even though the surrounding HTTP signature (if
+// any) was fine, since a forged proof would let a malicious
+// relay tamper with an activity while still delivering it with
+// its own, valid, transient signature.
+func (f *federatingActor) resolveActivity(ctx context.Context, b []byte) (pub.Activity, map[string]interface{}, gtserror.WithCode) {
 	var rawActivity map[string]interface{}
 	if err := json.Unmarshal(b, &rawActivity); err != nil {
 		err = fmt.Errorf("error unmarshalling request body: %w", err)
-		return nil, gtserror.NewErrorInternalError(err)
+		return nil, nil, gtserror.NewErrorInternalError(err)
+	}
+
+	if hadProof, err := f.verifyObjectIntegrityProof(ctx, rawActivity); err != nil {
+		return nil, nil, gtserror.NewErrorBadRequest(err, err.Error())
+	} else if hadProof {
+		log.WithContext(ctx).Debug("resolveActivity: verified embedded FEP-8b32 object integrity proof")
 	}
 
 	t, err := streams.ToType(ctx, rawActivity)
@@ -265,24 +537,24 @@ func resolveActivity(ctx context.Context, r *http.Request) (pub.Activity, gtserr
 		if !streams.IsUnmatchedErr(err) {
 			// Real error.
 			err = fmt.Errorf("error matching json to type: %w", err)
-			return nil, gtserror.NewErrorInternalError(err)
+			return nil, nil, gtserror.NewErrorInternalError(err)
 		}
 
 		// Respond with bad request; we just couldn't
 		// match the type to one that we know about.
 		err = errors.New("body json could not be resolved to ActivityStreams value")
-		return nil, gtserror.NewErrorBadRequest(err, err.Error())
+		return nil, nil, gtserror.NewErrorBadRequest(err, err.Error())
 	}
 
 	activity, ok := t.(pub.Activity)
 	if !ok {
 		err = fmt.Errorf("ActivityStreams value with type %T is not a pub.Activity", t)
-		return nil, gtserror.NewErrorBadRequest(err, err.Error())
+		return nil, nil, gtserror.NewErrorBadRequest(err, err.Error())
 	}
 
 	if activity.GetJSONLDId() == nil {
 		err = fmt.Errorf("incoming Activity %s did not have required id property set", activity.GetTypeName())
-		return nil, gtserror.NewErrorBadRequest(err, err.Error())
+		return nil, nil, gtserror.NewErrorBadRequest(err, err.Error())
 	}
 
 	// If activity Object is a Statusable, we'll want to replace the
@@ -291,7 +563,7 @@ func resolveActivity(ctx context.Context, r *http.Request) (pub.Activity, gtserr
 	// Likewise, if it's an Accountable, we'll normalize some fields on it.
 	ap.NormalizeIncomingActivityObject(activity, rawActivity)
 
-	return activity, nil
+	return activity, rawActivity, nil
 }
 
 /*
@@ -305,6 +577,16 @@ func (f *federatingActor) PostInbox(c context.Context, w http.ResponseWriter, r
 
 func (f *federatingActor) Send(c context.Context, outbox *url.URL, t vocab.Type) (pub.Activity, error) {
 	log.Infof(c, "send activity %s via outbox %s", t.GetTypeName(), outbox)
+
+	if f.signer != nil {
+		// go-fed's Send only threads a context.Context through to
+		// the transport controller that actually issues each
+		// per-recipient delivery POST, so the signer has to
+		// travel alongside it rather than being applied directly
+		// here; see OutgoingSignerFromContext in httpsignature.go.
+		c = withOutgoingSigner(c, f.signer)
+	}
+
 	return f.wrapped.Send(c, outbox, t)
 }
 
@@ -316,8 +598,75 @@ func (f *federatingActor) PostOutbox(c context.Context, w http.ResponseWriter, r
 	return f.wrapped.PostOutbox(c, w, r)
 }
 
-func (f *federatingActor) PostOutboxScheme(c context.Context, w http.ResponseWriter, r *http.Request, scheme string) (bool, error) {
-	return f.wrapped.PostOutboxScheme(c, w, r, scheme)
+// PostOutboxScheme is a reimplementation of the default baseActor
+// implementation of PostOutboxScheme in pub/base_actor.go, adding
+// the extra AuthorizePostOutbox step (over and above go-fed's
+// baseline authentication) so that C2S submissions are checked
+// against the submitting account's OAuth scope as well as its
+// identity, mirroring the Authenticate/Authorize split already
+// used for S2S requests in PostInboxScheme.
+func (f *federatingActor) PostOutboxScheme(ctx context.Context, w http.ResponseWriter, r *http.Request, scheme string) (bool, error) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		err = fmt.Errorf("error reading request body: %w", err)
+		return false, gtserror.NewErrorInternalError(err)
+	}
+
+	if ct := r.Header.Get("Content-Type"); !IsASMediaTypeWithBody(ct, body) {
+		const ct1 = "application/activity+json"
+		const ct2 = "application/ld+json;profile=" + asNamespaceIRI
+		err := fmt.Errorf("Content-Type %s not acceptable, this endpoint accepts: [%q %q]", ct, ct1, ct2)
+		return false, gtserror.NewErrorNotAcceptable(err)
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx, authenticated, err := f.sideEffectActor.AuthenticatePostOutbox(ctx, w, r)
+	if err != nil {
+		return false, gtserror.NewErrorInternalError(err)
+	}
+
+	if !authenticated {
+		err = errors.New("not authenticated")
+		return false, gtserror.NewErrorUnauthorized(err)
+	}
+
+	activity, _, errWithCode := f.resolveActivity(ctx, body)
+	if errWithCode != nil {
+		return false, errWithCode
+	}
+
+	ctx, err = f.sideEffectActor.PostOutboxRequestBodyHook(ctx, r, activity)
+	if err != nil {
+		return false, gtserror.NewErrorInternalError(err)
+	}
+
+	if authorizer, ok := f.c2s.(c2sAuthorizer); ok {
+		authorized, err := authorizer.AuthorizePostOutbox(ctx, w, activity)
+		if err != nil {
+			return false, gtserror.NewErrorInternalError(err)
+		}
+
+		if !authorized {
+			err = errors.New("not authorized to perform this activity")
+			return false, gtserror.NewErrorForbidden(err)
+		}
+	}
+
+	activityID, err := f.sideEffectActor.PostOutbox(ctx, activity, *r.URL)
+	if err != nil {
+		if errors.Is(err, pub.ErrObjectRequired) || errors.Is(err, pub.ErrTargetRequired) {
+			err = errors.New("malformed outgoing Activity: an Object and/or Target was required but not set")
+			return false, gtserror.NewErrorBadRequest(err, err.Error())
+		}
+
+		err = fmt.Errorf("PostOutboxScheme: error calling sideEffectActor.PostOutbox: %w", err)
+		return false, gtserror.NewErrorInternalError(err)
+	}
+
+	w.Header().Set("Location", activityID.String())
+	return true, nil
 }
 
 func (f *federatingActor) GetOutbox(c context.Context, w http.ResponseWriter, r *http.Request) (bool, error) {
@@ -0,0 +1,107 @@
+// GoToSocial
+// Copyright (C) GoToSocial Authors admin@gotosocial.org
+// SPDX-License-Identifier: AGPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package federation
+
+import "testing"
+
+func TestIsASMediaType(t *testing.T) {
+	tests := []struct {
+		name string
+		ct   string
+		want bool
+	}{
+		{"activity+json", "application/activity+json", true},
+		{"activity+json with charset", "application/activity+json; charset=utf-8", true},
+		{"ld+json with AS2 profile", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, true},
+		{"ld+json with http AS2 profile", `application/ld+json; profile="http://www.w3.org/ns/activitystreams"`, true},
+		{"ld+json with unrelated profile", `application/ld+json; profile="https://example.org/other"`, false},
+		{"ld+json with no profile, no body", "application/ld+json", false},
+		{"plain json", "application/json", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsASMediaType(tt.ct); got != tt.want {
+				t.Errorf("IsASMediaType(%q) = %v, want %v", tt.ct, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsASMediaTypeWithBody(t *testing.T) {
+	as2Body := []byte(`{"@context":"https://www.w3.org/ns/activitystreams","type":"Note"}`)
+	otherBody := []byte(`{"@context":"https://example.org/other","type":"Thing"}`)
+
+	tests := []struct {
+		name string
+		ct   string
+		body []byte
+		want bool
+	}{
+		{"ld+json no profile, AS2 body", "application/ld+json; charset=utf-8", as2Body, true},
+		{"ld+json no profile, unrelated body", "application/ld+json; charset=utf-8", otherBody, false},
+		{"ld+json no profile, nil body", "application/ld+json", nil, false},
+		{"activity+json ignores body", "application/activity+json", otherBody, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsASMediaTypeWithBody(tt.ct, tt.body); got != tt.want {
+				t.Errorf("IsASMediaTypeWithBody(%q, ...) = %v, want %v", tt.ct, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMediaTypeListOrdersByQuality(t *testing.T) {
+	candidates := parseMediaTypeList(`application/ld+json;q=0.5, application/activity+json;q=0.9, text/html;q=0.1`)
+
+	if len(candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(candidates))
+	}
+	if candidates[0].typ != "application/activity+json" {
+		t.Errorf("expected highest-q candidate first, got %q", candidates[0].typ)
+	}
+	if candidates[0].q != 0.9 {
+		t.Errorf("expected q=0.9, got %v", candidates[0].q)
+	}
+}
+
+func TestNegotiateASMediaType(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"prefers activity+json", "application/activity+json, text/html", "application/activity+json"},
+		{"ld+json with AS2 profile", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, "application/activity+json"},
+		{"bare ld+json offers our profile", "application/ld+json", "application/ld+json; profile=" + asNamespaceIRI},
+		{"wildcard", "application/*", "application/activity+json"},
+		{"nothing compatible", "text/html, image/png", ""},
+		{"q=0 excludes a candidate", "application/activity+json;q=0, text/html", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateASMediaType(tt.accept); got != tt.want {
+				t.Errorf("NegotiateASMediaType(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// GoToSocial
+// Copyright (C) GoToSocial Authors admin@gotosocial.org
+// SPDX-License-Identifier: AGPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package federation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// fakePublicKeyGetter resolves a single known keyID to pubKey,
+// for use as the PublicKeyGetter half of a sign/verify round trip.
+type fakePublicKeyGetter struct {
+	keyID  *url.URL
+	pubKey crypto.PublicKey
+	owner  *url.URL
+}
+
+func (f *fakePublicKeyGetter) GetPublicKey(_ context.Context, keyID *url.URL) (crypto.PublicKey, *url.URL, error) {
+	if keyID.String() != f.keyID.String() {
+		return nil, nil, errors.New("no such key")
+	}
+	return f.pubKey, f.owner, nil
+}
+
+func newTestRequest(t *testing.T, body []byte) *http.Request {
+	t.Helper()
+	r, err := http.NewRequest(http.MethodPost, "https://receiver.example/users/someone/inbox", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("building test request: %s", err)
+	}
+	r.Host = "receiver.example"
+	return r
+}
+
+func TestSignAndVerifyHTTPSignature(t *testing.T) {
+	keyID, err := url.Parse("https://sender.example/users/someone#main-key")
+	if err != nil {
+		t.Fatalf("parsing keyID: %s", err)
+	}
+
+	rsaPriv, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating rsa key: %s", err)
+	}
+
+	edPub, edPriv, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %s", err)
+	}
+
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		alg     sigAlgorithm
+		rfc9421 bool
+		priv    crypto.Signer
+		pub     crypto.PublicKey
+	}{
+		{"rsa-sha256 legacy", algRSASHA256, false, rsaPriv, &rsaPriv.PublicKey},
+		{"hs2019 rsa RFC9421", algHS2019, true, rsaPriv, &rsaPriv.PublicKey},
+		{"ed25519 legacy", algEd25519, false, edPriv, edPub},
+		{"ed25519 RFC9421", algEd25519, true, edPriv, edPub},
+		{"ecdsa-p256-sha256 legacy", algECDSAP256SHA256, false, ecPriv, &ecPriv.PublicKey},
+		{"ecdsa-p256-sha256 RFC9421", algECDSAP256SHA256, true, ecPriv, &ecPriv.PublicKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{"type":"Create","id":"https://sender.example/activities/1"}`)
+			r := newTestRequest(t, body)
+
+			signer := NewOutgoingSigner(keyID, tt.priv, tt.alg, tt.rfc9421)
+			if err := signer.SignRequest(r, body); err != nil {
+				t.Fatalf("SignRequest: %s", err)
+			}
+
+			keys := &fakePublicKeyGetter{keyID: keyID, pubKey: tt.pub, owner: keyID}
+
+			owner, err := verifyHTTPSignature(context.Background(), r, body, keys)
+			if err != nil {
+				t.Fatalf("verifyHTTPSignature: expected success, got %s", err)
+			}
+			if owner.String() != keyID.String() {
+				t.Fatalf("expected owner %s, got %s", keyID, owner)
+			}
+
+			// A tampered body must fail verification: the
+			// digest check should catch it even though the
+			// headers themselves are still validly signed.
+			tampered := append(bytes.TrimSuffix(body, []byte("}")), []byte(`,"extra":true}`)...)
+			if _, err := verifyHTTPSignature(context.Background(), r, tampered, keys); err == nil {
+				t.Fatalf("verifyHTTPSignature: expected failure for tampered body, got success")
+			}
+		})
+	}
+}
+
+func TestVerifyHTTPSignatureRejectsUnknownKey(t *testing.T) {
+	keyID, _ := url.Parse("https://sender.example/users/someone#main-key")
+	otherKeyID, _ := url.Parse("https://sender.example/users/someone-else#main-key")
+
+	edPub, edPriv, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %s", err)
+	}
+
+	body := []byte(`{"type":"Create"}`)
+	r := newTestRequest(t, body)
+
+	signer := NewOutgoingSigner(otherKeyID, edPriv, algEd25519, true)
+	if err := signer.SignRequest(r, body); err != nil {
+		t.Fatalf("SignRequest: %s", err)
+	}
+
+	keys := &fakePublicKeyGetter{keyID: keyID, pubKey: edPub, owner: keyID}
+	if _, err := verifyHTTPSignature(context.Background(), r, body, keys); err == nil {
+		t.Fatalf("verifyHTTPSignature: expected failure for unknown keyId, got success")
+	}
+}
@@ -0,0 +1,282 @@
+// GoToSocial
+// Copyright (C) GoToSocial Authors admin@gotosocial.org
+// SPDX-License-Identifier: AGPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package federation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gowebpki/jcs"
+	"github.com/mr-tron/base58"
+	"github.com/superseriousbusiness/activity/pub"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+	"github.com/superseriousbusiness/gotosocial/internal/ap"
+	"github.com/superseriousbusiness/gotosocial/internal/log"
+)
+
+// FEP-8b32 (https://w3id.org/fep/8b32) Object Integrity Proofs let
+// an activity carry its own embedded signature, so that a server
+// forwarding it to a third party doesn't have to be trusted: the
+// receiving server can verify the `proof` against the issuing
+// actor's public key directly, without re-deriving trust from the
+// transient HTTP signature of whichever server relayed it.
+
+const (
+	proofType        = "DataIntegrityProof"
+	proofCryptosuite = "eddsa-jcs-2022"
+	proofPurpose     = "assertionMethod"
+)
+
+// ldProof is the `proof` property embedded in an Activity's JSON,
+// per the Data Integrity (eddsa-jcs-2022) cryptosuite.
+type ldProof struct {
+	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite"`
+	Created            string `json:"created"`
+	VerificationMethod string `json:"verificationMethod"`
+	ProofPurpose       string `json:"proofPurpose"`
+	ProofValue         string `json:"proofValue"`
+}
+
+// AssertionKeyGetter resolves the Ed25519 assertionMethod key of
+// the local account with the given actor IRI, used to attach an
+// outgoing FEP-8b32 proof to activities sent by that account.
+type AssertionKeyGetter interface {
+	GetAssertionKey(ctx context.Context, actorIRI *url.URL) (keyID *url.URL, priv ed25519.PrivateKey, err error)
+}
+
+// canonicalize produces the JCS (RFC 8785) canonical form of doc,
+// which is what both proof creation and verification sign/hash.
+func canonicalize(doc map[string]interface{}) ([]byte, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling document: %w", err)
+	}
+
+	canonical, err := jcs.Transform(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error canonicalizing document: %w", err)
+	}
+
+	return canonical, nil
+}
+
+// verifyObjectIntegrityProof checks the `proof` property embedded
+// in rawActivity (if any) against the assertionMethod key of the
+// actor named in proof.verificationMethod. It returns (false, nil)
+// if the activity simply has no proof to check (this is not an
+// error; not every peer supports FEP-8b32 yet).
+func (f *federatingActor) verifyObjectIntegrityProof(ctx context.Context, rawActivity map[string]interface{}) (bool, error) {
+	rawProof, ok := rawActivity["proof"]
+	if !ok {
+		return false, nil
+	}
+
+	proofMap, ok := rawProof.(map[string]interface{})
+	if !ok {
+		return false, errors.New("verifyObjectIntegrityProof: proof property was not an object")
+	}
+
+	proof, err := decodeProof(proofMap)
+	if err != nil {
+		return false, fmt.Errorf("verifyObjectIntegrityProof: %w", err)
+	}
+
+	if proof.Type != proofType || proof.Cryptosuite != proofCryptosuite {
+		return false, fmt.Errorf("verifyObjectIntegrityProof: unsupported proof type/cryptosuite %s/%s", proof.Type, proof.Cryptosuite)
+	}
+
+	verificationMethod, err := url.Parse(proof.VerificationMethod)
+	if err != nil {
+		return false, fmt.Errorf("verifyObjectIntegrityProof: invalid verificationMethod %q: %w", proof.VerificationMethod, err)
+	}
+
+	pubKey, _, err := f.keys.GetPublicKey(ctx, verificationMethod)
+	if err != nil {
+		return false, fmt.Errorf("verifyObjectIntegrityProof: error fetching assertionMethod key %s: %w", verificationMethod, err)
+	}
+
+	edKey, ok := pubKey.(ed25519.PublicKey)
+	if !ok {
+		return false, fmt.Errorf("verifyObjectIntegrityProof: verificationMethod %s is not an Ed25519 key", verificationMethod)
+	}
+
+	sig, err := base58.Decode(strings.TrimPrefix(proof.ProofValue, "z"))
+	if err != nil {
+		return false, fmt.Errorf("verifyObjectIntegrityProof: error decoding proofValue: %w", err)
+	}
+
+	signingInput, err := ldProofSigningInput(rawActivity, proofMap)
+	if err != nil {
+		return false, fmt.Errorf("verifyObjectIntegrityProof: %w", err)
+	}
+
+	if !ed25519.Verify(edKey, signingInput, sig) {
+		return false, errors.New("verifyObjectIntegrityProof: signature verification failed")
+	}
+
+	return true, nil
+}
+
+// ldProofSigningInput builds the eddsa-jcs-2022 "hash data" that's
+// actually signed/verified: the SHA-256 hash of the JCS-canonical
+// proof configuration (the proof object, sans proofValue), followed
+// by the SHA-256 hash of the JCS-canonical document (the activity,
+// with its "proof" property removed entirely) — NOT a single hash
+// of the two merged together. Hashing them separately is what lets
+// a verifier recompute the document hash once and reuse it across
+// multiple proofs, and is what the Data Integrity spec and every
+// interoperating eddsa-jcs-2022 implementation actually do.
+func ldProofSigningInput(rawActivity, proofMap map[string]interface{}) ([]byte, error) {
+	proofConfig := make(map[string]interface{}, len(proofMap)+1)
+	for k, v := range proofMap {
+		if k == "proofValue" {
+			continue
+		}
+		proofConfig[k] = v
+	}
+	if ctxVal, ok := rawActivity["@context"]; ok {
+		proofConfig["@context"] = ctxVal
+	}
+
+	document := make(map[string]interface{}, len(rawActivity))
+	for k, v := range rawActivity {
+		if k == "proof" {
+			continue
+		}
+		document[k] = v
+	}
+
+	configCanonical, err := canonicalize(proofConfig)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalizing proof config: %w", err)
+	}
+	docCanonical, err := canonicalize(document)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalizing document: %w", err)
+	}
+
+	configHash := sha256.Sum256(configCanonical)
+	docHash := sha256.Sum256(docCanonical)
+
+	signingInput := make([]byte, 0, len(configHash)+len(docHash))
+	signingInput = append(signingInput, configHash[:]...)
+	signingInput = append(signingInput, docHash[:]...)
+	return signingInput, nil
+}
+
+// attachObjectIntegrityProof generates a FEP-8b32 `proof` property
+// for rawActivity, signed with the given account's Ed25519
+// assertionMethod key, and inserts it into the document in place.
+func attachObjectIntegrityProof(rawActivity map[string]interface{}, keyID *url.URL, priv ed25519.PrivateKey) error {
+	proofMap := map[string]interface{}{
+		"type":               proofType,
+		"cryptosuite":        proofCryptosuite,
+		"created":            time.Now().UTC().Format(time.RFC3339),
+		"verificationMethod": keyID.String(),
+		"proofPurpose":       proofPurpose,
+	}
+
+	signingInput, err := ldProofSigningInput(rawActivity, proofMap)
+	if err != nil {
+		return fmt.Errorf("attachObjectIntegrityProof: %w", err)
+	}
+
+	sig := ed25519.Sign(priv, signingInput)
+	proofMap["proofValue"] = "z" + base58.Encode(sig)
+
+	rawActivity["proof"] = proofMap
+	return nil
+}
+
+// serializeWithProof is used in place of ap.Serialize as the
+// federating actor's Serialize hook, so that every outgoing
+// Activity gets a FEP-8b32 object integrity proof attached before
+// it's delivered, in addition to being covered by the transient
+// HTTP signature applied when the request itself is sent. This
+// lets a downstream server that receives the activity via inbox
+// forwarding (rather than directly from us) still verify it came
+// from the actor it claims to.
+func (f *federatingActor) serializeWithProof(t vocab.Type) (map[string]interface{}, error) {
+	rawActivity, err := ap.Serialize(t)
+	if err != nil {
+		return nil, err
+	}
+
+	activity, ok := t.(pub.Activity)
+	if !ok {
+		// Not an Activity (eg., this may be an Actor document
+		// being served for GetInbox/GetOutbox); nothing to sign.
+		return rawActivity, nil
+	}
+
+	actorProp := activity.GetActivityStreamsActor()
+	if actorProp == nil || actorProp.Len() == 0 {
+		return rawActivity, nil
+	}
+	actorIRI := actorProp.At(0).GetIRI()
+	if actorIRI == nil {
+		return rawActivity, nil
+	}
+
+	keyID, priv, err := f.signingKeys.GetAssertionKey(context.Background(), actorIRI)
+	if err != nil {
+		// We can still deliver the activity relying on the
+		// transient HTTP signature alone; log and move on
+		// rather than failing the whole delivery.
+		log.Warnf(context.Background(), "serializeWithProof: could not get assertion key for %s: %s", actorIRI, err)
+		return rawActivity, nil
+	}
+
+	if err := attachObjectIntegrityProof(rawActivity, keyID, priv); err != nil {
+		return nil, fmt.Errorf("serializeWithProof: %w", err)
+	}
+
+	return rawActivity, nil
+}
+
+// decodeProof maps a raw `proof` JSON object onto an ldProof struct.
+func decodeProof(proofMap map[string]interface{}) (*ldProof, error) {
+	get := func(key string) string {
+		v, _ := proofMap[key].(string)
+		return v
+	}
+
+	proof := &ldProof{
+		Type:               get("type"),
+		Cryptosuite:        get("cryptosuite"),
+		Created:            get("created"),
+		VerificationMethod: get("verificationMethod"),
+		ProofPurpose:       get("proofPurpose"),
+		ProofValue:         get("proofValue"),
+	}
+
+	if proof.ProofValue == "" {
+		return nil, errors.New("proof missing proofValue")
+	}
+
+	return proof, nil
+}
+
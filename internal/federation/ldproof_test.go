@@ -0,0 +1,89 @@
+// GoToSocial
+// Copyright (C) GoToSocial Authors admin@gotosocial.org
+// SPDX-License-Identifier: AGPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package federation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+type fakeKeyGetter struct {
+	keyID *url.URL
+	pub   ed25519.PublicKey
+}
+
+func (f *fakeKeyGetter) GetPublicKey(_ context.Context, keyID *url.URL) (crypto.PublicKey, *url.URL, error) {
+	if keyID.String() != f.keyID.String() {
+		return nil, nil, errors.New("unknown key")
+	}
+	return f.pub, keyID, nil
+}
+
+func TestObjectIntegrityProofRoundTrip(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %s", err)
+	}
+
+	keyID, err := url.Parse("https://sender.example/users/someone#assertion-key")
+	if err != nil {
+		t.Fatalf("parsing keyID: %s", err)
+	}
+
+	rawActivity := map[string]interface{}{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"type":     "Create",
+		"id":       "https://sender.example/activities/1",
+		"actor":    "https://sender.example/users/someone",
+	}
+
+	if err := attachObjectIntegrityProof(rawActivity, keyID, priv); err != nil {
+		t.Fatalf("attachObjectIntegrityProof: %s", err)
+	}
+
+	proof, ok := rawActivity["proof"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected a proof property to be attached")
+	}
+	if proof["proofValue"] == "" || proof["proofValue"] == nil {
+		t.Fatalf("expected a non-empty proofValue")
+	}
+
+	f := &federatingActor{keys: &fakeKeyGetter{keyID: keyID, pub: pub}}
+
+	ok, err = f.verifyObjectIntegrityProof(context.Background(), rawActivity)
+	if err != nil {
+		t.Fatalf("verifyObjectIntegrityProof: expected success, got %s", err)
+	}
+	if !ok {
+		t.Fatalf("verifyObjectIntegrityProof: expected a proof to be found and verified")
+	}
+
+	// Tampering with the document after signing (but leaving the
+	// proof itself untouched) must invalidate it, since the
+	// document hash is covered by the signature too.
+	rawActivity["actor"] = "https://attacker.example/users/someone-else"
+	if ok, err := f.verifyObjectIntegrityProof(context.Background(), rawActivity); err == nil && ok {
+		t.Fatalf("verifyObjectIntegrityProof: expected failure after tampering with the document")
+	}
+}
@@ -0,0 +1,163 @@
+// GoToSocial
+// Copyright (C) GoToSocial Authors admin@gotosocial.org
+// SPDX-License-Identifier: AGPL-3.0-or-later
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package federation
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/superseriousbusiness/activity/streams"
+)
+
+func mustParseTestURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing %q: %s", raw, err)
+	}
+	return u
+}
+
+func TestMemoryTombstoneStore(t *testing.T) {
+	store := newMemoryTombstoneStore()
+
+	const id = "https://sender.example/activities/1"
+
+	if store.IsTombstoned(id) {
+		t.Fatalf("expected %s not to be tombstoned yet", id)
+	}
+
+	store.Tombstone(id)
+
+	if !store.IsTombstoned(id) {
+		t.Fatalf("expected %s to be tombstoned after Tombstone()", id)
+	}
+
+	// A different ID is unaffected.
+	if store.IsTombstoned("https://sender.example/activities/2") {
+		t.Fatalf("expected an unrelated ID not to be tombstoned")
+	}
+}
+
+func TestScriptPolicyCompilesOnce(t *testing.T) {
+	const source = `
+calls = 0
+
+def evaluate(requester, activity_type, raw):
+    return "reject"
+`
+
+	policy, err := NewScriptPolicy(source)
+	if err != nil {
+		t.Fatalf("NewScriptPolicy: %s", err)
+	}
+
+	if policy.evaluate == nil {
+		t.Fatalf("expected evaluate to be compiled and stored on construction")
+	}
+
+	// Constructing with a script that doesn't define evaluate()
+	// should fail at construction time, not on first Evaluate().
+	if _, err := NewScriptPolicy("x = 1"); err == nil {
+		t.Fatalf("expected NewScriptPolicy to reject a script without evaluate()")
+	}
+}
+
+func TestDomainPolicy(t *testing.T) {
+	allow := &DomainPolicy{Allow: map[string]struct{}{"good.example": {}}}
+	if verdict, err := allow.Evaluate(nil, mustParseTestURL(t, "https://good.example/users/someone"), nil, nil); err != nil || verdict != PolicyAccept {
+		t.Fatalf("expected an allowlisted domain to be accepted, got verdict=%d err=%v", verdict, err)
+	}
+	if verdict, err := allow.Evaluate(nil, mustParseTestURL(t, "https://bad.example/users/someone"), nil, nil); err == nil || verdict != PolicyReject {
+		t.Fatalf("expected a non-allowlisted domain to be rejected, got verdict=%d err=%v", verdict, err)
+	}
+
+	deny := &DomainPolicy{Deny: map[string]struct{}{"bad.example": {}}}
+	if verdict, err := deny.Evaluate(nil, mustParseTestURL(t, "https://good.example/users/someone"), nil, nil); err != nil || verdict != PolicyAccept {
+		t.Fatalf("expected a non-denylisted domain to be accepted, got verdict=%d err=%v", verdict, err)
+	}
+	if verdict, err := deny.Evaluate(nil, mustParseTestURL(t, "https://bad.example/users/someone"), nil, nil); err == nil || verdict != PolicyReject {
+		t.Fatalf("expected a denylisted domain to be rejected, got verdict=%d err=%v", verdict, err)
+	}
+
+	// Matching is case-insensitive on the host.
+	if verdict, err := deny.Evaluate(nil, mustParseTestURL(t, "https://BAD.example/users/someone"), nil, nil); err == nil || verdict != PolicyReject {
+		t.Fatalf("expected domain matching to be case-insensitive, got verdict=%d err=%v", verdict, err)
+	}
+}
+
+func TestRateLimitPolicy(t *testing.T) {
+	policy := &RateLimitPolicy{Limit: 2, Window: time.Minute}
+	requester := mustParseTestURL(t, "https://sender.example/users/someone")
+	activity := streams.NewActivityStreamsCreate()
+
+	for i := 0; i < 2; i++ {
+		verdict, err := policy.Evaluate(nil, requester, activity, nil)
+		if err != nil || verdict != PolicyAccept {
+			t.Fatalf("request %d: expected PolicyAccept under the limit, got verdict=%d err=%v", i, verdict, err)
+		}
+	}
+
+	verdict, err := policy.Evaluate(nil, requester, activity, nil)
+	if err == nil || verdict != PolicyReject {
+		t.Fatalf("expected the request over the limit to be rejected, got verdict=%d err=%v", verdict, err)
+	}
+
+	// A different requester has its own, unaffected count.
+	other := mustParseTestURL(t, "https://other.example/users/someone")
+	if verdict, err := policy.Evaluate(nil, other, activity, nil); err != nil || verdict != PolicyAccept {
+		t.Fatalf("expected a different requester to have its own limit, got verdict=%d err=%v", verdict, err)
+	}
+}
+
+func TestSizePolicyMaxBytes(t *testing.T) {
+	small := map[string]interface{}{"type": "Create"}
+	large := map[string]interface{}{"type": "Create", "content": string(make([]byte, 1000))}
+
+	policy := &SizePolicy{MaxBytes: 100}
+
+	if verdict, err := policy.Evaluate(nil, nil, nil, small); err != nil || verdict != PolicyAccept {
+		t.Fatalf("expected a small activity to be accepted, got verdict=%d err=%v", verdict, err)
+	}
+	if verdict, err := policy.Evaluate(nil, nil, nil, large); err == nil || verdict != PolicyReject {
+		t.Fatalf("expected an activity over MaxBytes to be rejected, got verdict=%d err=%v", verdict, err)
+	}
+}
+
+func TestSizePolicyMaxAttachments(t *testing.T) {
+	policy := &SizePolicy{MaxAttachments: 1}
+
+	withinLimit := map[string]interface{}{
+		"object": map[string]interface{}{
+			"attachment": []interface{}{"one"},
+		},
+	}
+	overLimit := map[string]interface{}{
+		"object": map[string]interface{}{
+			"attachment": []interface{}{"one", "two"},
+		},
+	}
+
+	if verdict, err := policy.Evaluate(nil, nil, nil, withinLimit); err != nil || verdict != PolicyAccept {
+		t.Fatalf("expected an activity within MaxAttachments to be accepted, got verdict=%d err=%v", verdict, err)
+	}
+	if verdict, err := policy.Evaluate(nil, nil, nil, overLimit); err == nil || verdict != PolicyReject {
+		t.Fatalf("expected an activity over MaxAttachments to be rejected, got verdict=%d err=%v", verdict, err)
+	}
+}